@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FilesystemBackend implements RecordingBackend directly against a local
+// directory, for users who don't run EPGStation and just want mtime-based
+// aging of raw .ts/.m2ts recordings.
+//
+// EPGStation distinguishes a raw "ts" capture from its transcoded "encoded"
+// counterpart; a plain directory has no such distinction, so List reports
+// each discovered file as satisfying both roles. This lets it flow through
+// the same policy engine gate (hasTS && hasEncoded) unchanged.
+type FilesystemBackend struct {
+	rootDir string
+
+	mu        sync.Mutex
+	pathsById map[int64]string
+}
+
+func NewFilesystemBackend(rootDir string) *FilesystemBackend {
+	return &FilesystemBackend{rootDir: rootDir, pathsById: make(map[int64]string)}
+}
+
+var filesystemVideoExtensions = map[string]bool{
+	".ts":   true,
+	".m2ts": true,
+}
+
+// List walks rootDir (non-recursively) and returns one RecordedItem per
+// recognized video file, aged by its mtime.
+func (b *FilesystemBackend) List() ([]RecordedItem, error) {
+	entries, err := b.scan()
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]RecordedItem, 0, len(entries))
+	for _, entry := range entries {
+		videoFile := VideoFile{Id: entry.id, Name: entry.name, FileName: entry.name, Type: "ts", Size: entry.size}
+		encodedMarker := videoFile
+		encodedMarker.Type = "encoded"
+
+		items = append(items, RecordedItem{
+			Id:         entry.id,
+			Name:       entry.name,
+			StartAt:    entry.modTime.UnixMilli(),
+			VideoFiles: []VideoFile{videoFile, encodedMarker},
+		})
+	}
+
+	return items, nil
+}
+
+type filesystemEntry struct {
+	id      int64
+	name    string
+	size    int64
+	modTime time.Time
+}
+
+// scan walks rootDir and refreshes pathsById, so ids stay resolvable for
+// DeleteVideo even when called without a prior List (e.g. RUN_MODE=apply
+// replaying a plan file).
+func (b *FilesystemBackend) scan() ([]filesystemEntry, error) {
+	dirEntries, err := os.ReadDir(b.rootDir)
+	if err != nil {
+		return nil, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	entries := make([]filesystemEntry, 0, len(dirEntries))
+	for _, dirEntry := range dirEntries {
+		if dirEntry.IsDir() || !filesystemVideoExtensions[strings.ToLower(filepath.Ext(dirEntry.Name()))] {
+			continue
+		}
+
+		path := filepath.Join(b.rootDir, dirEntry.Name())
+		info, err := dirEntry.Info()
+		if err != nil {
+			return nil, err
+		}
+
+		id := fileId(path)
+		b.pathsById[id] = path
+
+		entries = append(entries, filesystemEntry{id: id, name: dirEntry.Name(), size: info.Size(), modTime: info.ModTime()})
+	}
+
+	return entries, nil
+}
+
+// DeleteVideo removes the file on disk reported with the given id. If id
+// isn't known yet (e.g. DeleteVideo is called without a prior List, as
+// RunApply does when replaying a plan file), it rescans rootDir first since
+// ids are derived deterministically from path.
+func (b *FilesystemBackend) DeleteVideo(id int64) error {
+	b.mu.Lock()
+	path, ok := b.pathsById[id]
+	b.mu.Unlock()
+
+	if !ok {
+		if _, err := b.scan(); err != nil {
+			return err
+		}
+
+		b.mu.Lock()
+		path, ok = b.pathsById[id]
+		b.mu.Unlock()
+	}
+
+	if !ok {
+		return fmt.Errorf("no known file for id %d", id)
+	}
+
+	return os.Remove(path)
+}
+
+// fileId derives a stable id for a file path so it can be looked up again on
+// a later DeleteVideo call without keeping a separate id-allocation file.
+func fileId(path string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(path))
+	return int64(h.Sum64())
+}
+
+// MirakurunBackend talks to a Mirakurun tuner server. Mirakurun only
+// schedules and streams broadcasts; it has no concept of a stored recorded
+// file, so this backend cannot list or delete recordings by itself. It
+// exists so BACKEND_TYPE=mirakurun fails loudly and explains why, rather
+// than silently behaving like EPGStation.
+type MirakurunBackend struct {
+	baseURL string
+}
+
+func NewMirakurunBackend(baseURL string) *MirakurunBackend {
+	return &MirakurunBackend{baseURL: baseURL}
+}
+
+func (b *MirakurunBackend) List() ([]RecordedItem, error) {
+	return nil, fmt.Errorf("mirakurun backend does not support listing recorded files (base URL: %s)", b.baseURL)
+}
+
+func (b *MirakurunBackend) DeleteVideo(id int64) error {
+	return fmt.Errorf("mirakurun backend does not support deleting recorded files (id: %d)", id)
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// Metrics holds the Prometheus-format counters exposed on /metrics. All
+// fields are updated with atomic operations so they can be read concurrently
+// from the HTTP handler while a sweep is in progress.
+type Metrics struct {
+	filesScanned    atomic.Int64
+	filesDeleted    atomic.Int64
+	bytesReclaimed  atomic.Int64
+	errors          atomic.Int64
+	lastSweepUnixMs atomic.Int64
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{}
+}
+
+func (m *Metrics) RecordFilesScanned(n int64) {
+	m.filesScanned.Add(n)
+}
+
+func (m *Metrics) RecordDeletion(bytes int64) {
+	m.filesDeleted.Add(1)
+	m.bytesReclaimed.Add(bytes)
+}
+
+func (m *Metrics) RecordError() {
+	m.errors.Add(1)
+}
+
+func (m *Metrics) RecordSweepCompleted() {
+	m.lastSweepUnixMs.Store(time.Now().UnixMilli())
+}
+
+// WritePrometheus writes the current counters in the Prometheus text
+// exposition format.
+func (m *Metrics) WritePrometheus(w http.ResponseWriter) {
+	fmt.Fprintf(w, "# HELP epgstation_file_deleter_files_scanned_total Number of recorded items scanned.\n")
+	fmt.Fprintf(w, "# TYPE epgstation_file_deleter_files_scanned_total counter\n")
+	fmt.Fprintf(w, "epgstation_file_deleter_files_scanned_total %d\n", m.filesScanned.Load())
+
+	fmt.Fprintf(w, "# HELP epgstation_file_deleter_files_deleted_total Number of video files deleted.\n")
+	fmt.Fprintf(w, "# TYPE epgstation_file_deleter_files_deleted_total counter\n")
+	fmt.Fprintf(w, "epgstation_file_deleter_files_deleted_total %d\n", m.filesDeleted.Load())
+
+	fmt.Fprintf(w, "# HELP epgstation_file_deleter_bytes_reclaimed_total Total bytes reclaimed by deletions.\n")
+	fmt.Fprintf(w, "# TYPE epgstation_file_deleter_bytes_reclaimed_total counter\n")
+	fmt.Fprintf(w, "epgstation_file_deleter_bytes_reclaimed_total %d\n", m.bytesReclaimed.Load())
+
+	fmt.Fprintf(w, "# HELP epgstation_file_deleter_errors_total Number of errors encountered during sweeps.\n")
+	fmt.Fprintf(w, "# TYPE epgstation_file_deleter_errors_total counter\n")
+	fmt.Fprintf(w, "epgstation_file_deleter_errors_total %d\n", m.errors.Load())
+
+	fmt.Fprintf(w, "# HELP epgstation_file_deleter_last_sweep_timestamp_seconds Unix timestamp of the last completed sweep.\n")
+	fmt.Fprintf(w, "# TYPE epgstation_file_deleter_last_sweep_timestamp_seconds gauge\n")
+	fmt.Fprintf(w, "epgstation_file_deleter_last_sweep_timestamp_seconds %f\n", float64(m.lastSweepUnixMs.Load())/1000)
+}
+
+// newMetricsServer builds the HTTP server serving /healthz and /metrics.
+func newMetricsServer(addr string, metrics *Metrics) *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		metrics.WritePrometheus(w)
+	})
+
+	return &http.Server{Addr: addr, Handler: mux}
+}
+
+// RunDaemon runs the deletion sweep on a time.Ticker until SIGINT/SIGTERM is
+// received, at which point it lets an in-flight sweep finish before shutting
+// down the metrics server and returning.
+func RunDaemon(config Config, backend RecordingBackend, policy DeletionPolicy, metrics *Metrics, sinks []ReportSink) {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	sweepInterval, err := time.ParseDuration(config.SweepInterval)
+	if err != nil {
+		logger.Error(err.Error())
+		return
+	}
+
+	server := newMetricsServer(config.MetricsAddr, metrics)
+	go func() {
+		logger.Info(fmt.Sprintf("Serving /healthz and /metrics on %s", config.MetricsAddr))
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Error(err.Error())
+		}
+	}()
+
+	logger.Info(fmt.Sprintf("Running in daemon mode with sweep interval %s", config.SweepInterval))
+
+	if err := runSweep(config, backend, policy, metrics, sinks); err != nil {
+		logger.Error(err.Error())
+	}
+
+	ticker := time.NewTicker(sweepInterval)
+	defer ticker.Stop()
+
+loop:
+	for {
+		select {
+		case <-ticker.C:
+			if err := runSweep(config, backend, policy, metrics, sinks); err != nil {
+				logger.Error(err.Error())
+			}
+		case <-ctx.Done():
+			logger.Info("Shutdown signal received, stopping daemon")
+			break loop
+		}
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		logger.Error(err.Error())
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// BuildHttpClient returns a plain, secure-by-default HTTP client for callers
+// (e.g. report webhook sinks) that don't need EPGStationClient's retry,
+// pagination, or auth machinery.
+func BuildHttpClient() http.Client {
+	return http.Client{Timeout: 30 * time.Second}
+}
+
+// TokenBucket is a minimal token-bucket rate limiter used to keep
+// DeleteVideoFile from hammering EPGStation during a large purge.
+type TokenBucket struct {
+	mu         sync.Mutex
+	tokens     float64
+	maxTokens  float64
+	refillRate float64 // tokens per second
+	lastRefill time.Time
+}
+
+func NewTokenBucket(ratePerSecond float64) *TokenBucket {
+	if ratePerSecond <= 0 {
+		ratePerSecond = 1
+	}
+	return &TokenBucket{tokens: ratePerSecond, maxTokens: ratePerSecond, refillRate: ratePerSecond, lastRefill: time.Now()}
+}
+
+// Wait blocks until a token is available.
+func (b *TokenBucket) Wait() {
+	for {
+		b.mu.Lock()
+		now := time.Now()
+		elapsed := now.Sub(b.lastRefill).Seconds()
+		b.tokens = min(b.maxTokens, b.tokens+elapsed*b.refillRate)
+		b.lastRefill = now
+
+		if b.tokens >= 1 {
+			b.tokens--
+			b.mu.Unlock()
+			return
+		}
+
+		wait := time.Duration((1 - b.tokens) / b.refillRate * float64(time.Second))
+		b.mu.Unlock()
+		time.Sleep(wait)
+	}
+}
+
+// EPGStationClient is a RecordingBackend implementation talking to the
+// EPGStation HTTP API. It retries transient failures with exponential
+// backoff, paginates /api/recorded, and rate-limits deletes.
+type EPGStationClient struct {
+	baseURL         string
+	httpClient      http.Client
+	pageLimit       int
+	maxRetries      int
+	bearerToken     string
+	basicAuthUser   string
+	basicAuthPass   string
+	deleteRateLimit *TokenBucket
+}
+
+// NewEPGStationClient builds an EPGStationClient from config, including its
+// TLS settings, timeout, auth headers, and delete rate limit.
+func NewEPGStationClient(config Config) (*EPGStationClient, error) {
+	timeout, err := time.ParseDuration(config.EpgStationTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("invalid EPGSTATION_TIMEOUT: %w", err)
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: config.EpgStationInsecureSkipVerify}
+	if config.EpgStationCACertPath != "" {
+		pem, err := os.ReadFile(config.EpgStationCACertPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read EPGSTATION_CA_CERT_PATH: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in EPGSTATION_CA_CERT_PATH %s", config.EpgStationCACertPath)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	httpClient := http.Client{
+		Timeout:   timeout,
+		Transport: &http.Transport{TLSClientConfig: tlsConfig},
+	}
+
+	pageLimit := config.EpgStationPageLimit
+	if pageLimit <= 0 {
+		pageLimit = 100
+	}
+
+	return &EPGStationClient{
+		baseURL:         config.EpgStationBaseURL,
+		httpClient:      httpClient,
+		pageLimit:       pageLimit,
+		maxRetries:      config.EpgStationMaxRetries,
+		bearerToken:     config.EpgStationBearerToken,
+		basicAuthUser:   config.EpgStationBasicAuthUser,
+		basicAuthPass:   config.EpgStationBasicAuthPass,
+		deleteRateLimit: NewTokenBucket(config.EpgStationDeleteRatePerSec),
+	}, nil
+}
+
+func (client *EPGStationClient) authenticate(req *http.Request) {
+	if client.bearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+client.bearerToken)
+	} else if client.basicAuthUser != "" {
+		req.SetBasicAuth(client.basicAuthUser, client.basicAuthPass)
+	}
+}
+
+// doWithRetry executes req, retrying with exponential backoff and jitter on
+// network errors and 5xx responses. reqFn rebuilds the request on each
+// attempt since an http.Request can't be replayed once its body is read.
+func (client *EPGStationClient) doWithRetry(reqFn func() (*http.Request, error)) (*http.Response, error) {
+	var lastErr error
+
+	for attempt := 0; attempt <= client.maxRetries; attempt++ {
+		if attempt > 0 {
+			backoff := time.Duration(1<<uint(attempt-1)) * 200 * time.Millisecond
+			jitter := time.Duration(rand.Int63n(int64(backoff) + 1))
+			time.Sleep(backoff + jitter)
+		}
+
+		req, err := reqFn()
+		if err != nil {
+			return nil, err
+		}
+		client.authenticate(req)
+
+		resp, err := client.httpClient.Do(req)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		if resp.StatusCode >= 500 {
+			resp.Body.Close()
+			lastErr = fmt.Errorf("EPGStation returned status %d", resp.StatusCode)
+			continue
+		}
+
+		return resp, nil
+	}
+
+	return nil, fmt.Errorf("giving up after %d attempt(s): %w", client.maxRetries+1, lastErr)
+}
+
+// GetRecorded fetches every recorded item from EPGStation, paginating
+// through /api/recorded via offset/limit until a short page is returned.
+func (client *EPGStationClient) GetRecorded() (*Records, error) {
+	all := Records{}
+
+	for offset := 0; ; offset += client.pageLimit {
+		url := fmt.Sprintf("%s/api/recorded?isHalfWidth=true&limit=%d&offset=%d", client.baseURL, client.pageLimit, offset)
+
+		resp, err := client.doWithRetry(func() (*http.Request, error) {
+			return http.NewRequest(http.MethodGet, url, nil)
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		var page Records
+		if err := json.Unmarshal(body, &page); err != nil {
+			return nil, fmt.Errorf("failed to decode /api/recorded response: %w", err)
+		}
+
+		all.TotalCount = page.TotalCount
+		all.RecordItems = append(all.RecordItems, page.RecordItems...)
+
+		if len(page.RecordItems) < client.pageLimit {
+			break
+		}
+	}
+
+	return &all, nil
+}
+
+// List implements RecordingBackend by fetching all recorded items from
+// EPGStation.
+func (client *EPGStationClient) List() ([]RecordedItem, error) {
+	r, err := client.GetRecorded()
+	if err != nil {
+		return nil, err
+	}
+	return r.RecordItems, nil
+}
+
+// DeleteVideo implements RecordingBackend by deleting the given video file
+// through EPGStation's API.
+func (client *EPGStationClient) DeleteVideo(id int64) error {
+	return client.DeleteVideoFile(id)
+}
+
+func (client *EPGStationClient) DeleteVideoFile(videoFileId int64) error {
+	client.deleteRateLimit.Wait()
+
+	url := fmt.Sprintf("%s/api/videos/%d", client.baseURL, videoFileId)
+
+	resp, err := client.doWithRetry(func() (*http.Request, error) {
+		return http.NewRequest(http.MethodDelete, url, nil)
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("status code is not 200 but %d, body: %s", resp.StatusCode, bytes.TrimSpace(body))
+	}
+
+	return nil
+}
@@ -1,13 +1,9 @@
 package main
 
 import (
-	"crypto/tls"
-	"encoding/json"
 	"fmt"
 	"github.com/caarlos0/env/v10"
-	"io"
 	"log/slog"
-	"net/http"
 	"os"
 	"time"
 )
@@ -19,23 +15,59 @@ type Config struct {
 	RetainDuration    string `env:"RETAIN_DURATION" envDefault:"336h"`
 	IsDryRun          bool   `env:"IS_DRY_RUN" envDefault:"false"`
 	LogLevel          string `env:"LOG_LEVEL" envDefault:"INFO"`
+	RunMode           string `env:"RUN_MODE" envDefault:"oneshot"`
+	SweepInterval     string `env:"SWEEP_INTERVAL" envDefault:"6h"`
+	MetricsAddr       string `env:"METRICS_ADDR" envDefault:":8080"`
+	PolicyConfigPath  string `env:"POLICY_CONFIG_PATH"`
+	BackendType       string `env:"BACKEND_TYPE" envDefault:"epgstation"`
+	FilesystemDir     string `env:"FILESYSTEM_DIR"`
+	MirakurunBaseURL  string `env:"MIRAKURUN_BASE_URL" envDefault:"http://localhost:40772"`
+	DiscordWebhookURL string `env:"DISCORD_WEBHOOK_URL"`
+	SlackWebhookURL   string `env:"SLACK_WEBHOOK_URL"`
+	ReportWebhookURL  string `env:"REPORT_WEBHOOK_URL"`
+	ReportFileDir     string `env:"REPORT_FILE_DIR"`
+	NotifyOnlyOnError bool   `env:"NOTIFY_ONLY_ON_ERROR" envDefault:"false"`
+
+	EpgStationInsecureSkipVerify bool    `env:"EPGSTATION_INSECURE_SKIP_VERIFY" envDefault:"false"`
+	EpgStationCACertPath         string  `env:"EPGSTATION_CA_CERT_PATH"`
+	EpgStationTimeout            string  `env:"EPGSTATION_TIMEOUT" envDefault:"30s"`
+	EpgStationPageLimit          int     `env:"EPGSTATION_PAGE_LIMIT" envDefault:"100"`
+	EpgStationMaxRetries         int     `env:"EPGSTATION_MAX_RETRIES" envDefault:"3"`
+	EpgStationDeleteRatePerSec   float64 `env:"EPGSTATION_DELETE_RATE_PER_SEC" envDefault:"2"`
+	EpgStationBearerToken        string  `env:"EPGSTATION_BEARER_TOKEN"`
+	EpgStationBasicAuthUser      string  `env:"EPGSTATION_BASIC_AUTH_USER"`
+	EpgStationBasicAuthPass      string  `env:"EPGSTATION_BASIC_AUTH_PASS"`
+
+	Interactive   bool   `env:"INTERACTIVE" envDefault:"false"`
+	PlanFile      string `env:"PLAN_FILE"`
+	ApplyPlanFile string `env:"APPLY_PLAN_FILE"`
 }
 
-type EPGStationClient struct {
-	baseURL string
+// RecordingBackend abstracts over where recordings live and how they are
+// deleted, so the sweep logic in runSweep/RunDaemon doesn't need to know
+// whether it's talking to EPGStation, a plain filesystem directory, or
+// Mirakurun.
+type RecordingBackend interface {
+	List() ([]RecordedItem, error)
+	DeleteVideo(id int64) error
 }
 
-func NewEPGStationClient(baseURL string) EPGStationClient {
-	return EPGStationClient{baseURL: baseURL}
-}
-
-type DeletionPolicy struct {
-	RetainDuration time.Duration
-}
-
-func NewDeletionPolicy(retainDuration time.Duration) DeletionPolicy {
-	// Default is 2 weeks
-	return DeletionPolicy{RetainDuration: retainDuration}
+// NewRecordingBackend builds the RecordingBackend selected by
+// config.BackendType.
+func NewRecordingBackend(config Config) (RecordingBackend, error) {
+	switch config.BackendType {
+	case "epgstation":
+		return NewEPGStationClient(config)
+	case "filesystem":
+		if config.FilesystemDir == "" {
+			return nil, fmt.Errorf("FILESYSTEM_DIR must be set when BACKEND_TYPE=filesystem")
+		}
+		return NewFilesystemBackend(config.FilesystemDir), nil
+	case "mirakurun":
+		return NewMirakurunBackend(config.MirakurunBaseURL), nil
+	default:
+		return nil, fmt.Errorf("unknown backend type %q", config.BackendType)
+	}
 }
 
 type Records struct {
@@ -51,6 +83,13 @@ type RecordedItem struct {
 	StartAt     int64       `json:"startAt"`
 	EndAt       int64       `json:"endAt"`
 	VideoFiles  []VideoFile `json:"videoFiles"`
+	// RuleId identifies the EPGStation recording rule that produced this
+	// item, i.e. its "series" for grouping purposes. Manually recorded
+	// items have no rule and are left nil.
+	RuleId *int64 `json:"ruleId"`
+	// GenreIds are EPGStation genre codes (e.g. the program's primary and
+	// secondary genre) attached to the recording.
+	GenreIds []int64 `json:"genre"`
 }
 
 type VideoFile struct {
@@ -61,74 +100,73 @@ type VideoFile struct {
 	Size     int64  `json:"size"`
 }
 
-func BuildHttpClient() http.Client {
-	tr := http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-	}
-	return http.Client{Transport: &tr}
+func extractTargetRecordItems(src []RecordedItem, policy DeletionPolicy) []DeletionCandidate {
+	return policy.Engine.Evaluate(src)
 }
 
-func (client *EPGStationClient) GetRecorded() (*Records, error) {
-	url := fmt.Sprintf("%s/api/recorded?isHalfWidth=true&limit=0", client.baseURL)
-	hc := BuildHttpClient()
-	//resp, err := http.Get(url)
-	resp, err := hc.Get(url)
+// runSweep performs a single scan-and-delete pass against backend, reports
+// its outcome through metrics, and notifies the configured report sinks. It
+// is shared by oneshot and daemon run modes so both observe identical
+// deletion behavior.
+func runSweep(config Config, backend RecordingBackend, policy DeletionPolicy, metrics *Metrics, sinks []ReportSink) error {
+	records, err := backend.List()
 	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
+		metrics.RecordError()
+		return err
 	}
 
-	var r Records
-	json.Unmarshal(body, &r)
+	candidates := extractTargetRecordItems(records, policy)
 
-	return &r, nil
-}
+	metrics.RecordFilesScanned(int64(len(records)))
 
-func (client *EPGStationClient) DeleteVideoFile(videoFileId int64) error {
-	url := fmt.Sprintf("%s/api/videos/%d", client.baseURL, videoFileId)
-	req, err := http.NewRequest(http.MethodDelete, url, nil)
-	if err != nil {
-		return err
-	}
+	plan := BuildPlan(candidates)
 
-	hc := BuildHttpClient()
-	res, err := hc.Do(req)
-	if err != nil {
-		return err
+	if config.PlanFile != "" {
+		if err := WritePlanFile(plan, config.PlanFile); err != nil {
+			logger.Error(err.Error())
+			metrics.RecordError()
+			return err
+		}
+		logger.Info(fmt.Sprintf("Wrote plan to %s: %s", config.PlanFile, plan.Summary()))
 	}
 
-	if res.StatusCode != 200 {
-		return fmt.Errorf("Status code is not 200 but %d. response is %v\n", res.StatusCode, res)
+	if config.Interactive {
+		if !confirmPlan(plan, os.Stdin, os.Stdout) {
+			logger.Info("Deletion plan declined interactively, skipping this sweep")
+			return nil
+		}
 	}
 
-	return nil
-}
-
-func extractTargetRecordItems(src []RecordedItem, policy DeletionPolicy, dst *[]RecordedItem) {
-	for _, record := range src {
-		hasTS := false
-		hasEncoded := false
+	report := NewSweepReport()
 
-		for _, vf := range record.VideoFiles {
-			if vf.Type == "ts" {
-				hasTS = true
-			} else if vf.Type == "encoded" {
-				hasEncoded = true
+	for _, candidate := range candidates {
+		record := candidate.Record
+		for _, videoFile := range record.VideoFiles {
+			if videoFile.Type == "ts" {
+				if config.IsDryRun {
+					logger.Info(fmt.Sprintf("(Dry Run) Delete videoFile id: %d, filename: %s", videoFile.Id, videoFile.FileName))
+					report.AddDeletion(record, videoFile, candidate.Reason)
+				} else {
+					logger.Info(fmt.Sprintf("Delete videoFile id: %d, filename: %s", videoFile.Id, videoFile.FileName))
+					err := backend.DeleteVideo(videoFile.Id)
+					if err != nil {
+						logger.Error(err.Error())
+						metrics.RecordError()
+						report.AddError(err)
+						continue
+					}
+					metrics.RecordDeletion(videoFile.Size)
+					report.AddDeletion(record, videoFile, candidate.Reason)
+				}
 			}
 		}
+	}
 
-		elapsed := time.Since(time.UnixMilli(record.StartAt))
-		logger.Debug(fmt.Sprintf("Check if record satisfy deletion policy id: %d, name: %s, protected: %t, hasTS: %t, hasEncoded: %t, elapsed: %s", record.Id, record.Name, record.IsProtected, hasTS, hasEncoded, elapsed.String()))
+	metrics.RecordSweepCompleted()
 
-		if !record.IsProtected && hasTS && hasEncoded && elapsed > policy.RetainDuration {
-			*dst = append(*dst, record)
-		}
-	}
+	NotifySinks(sinks, report, config.NotifyOnlyOnError)
+
+	return nil
 }
 
 func main() {
@@ -169,32 +207,46 @@ func main() {
 
 	logger.Info(fmt.Sprintf("Retain duration is %s", config.RetainDuration))
 
-	epgStationClient := NewEPGStationClient(config.EpgStationBaseURL)
-
-	r, err := epgStationClient.GetRecorded()
+	backend, err := NewRecordingBackend(config)
 	if err != nil {
 		logger.Error(err.Error())
 		os.Exit(1)
 	}
 
-	policy := NewDeletionPolicy(retainDuration)
-	dst := make([]RecordedItem, 0)
-	extractTargetRecordItems(r.RecordItems, policy, &dst)
+	var policy DeletionPolicy
+	if config.PolicyConfigPath != "" {
+		policy, err = NewDeletionPolicyFromConfig(config.PolicyConfigPath)
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+		logger.Info(fmt.Sprintf("Loaded deletion policy rules from %s", config.PolicyConfigPath))
+	} else {
+		policy = NewDeletionPolicy(retainDuration)
+	}
 
-	for _, record := range dst {
-		for _, videoFile := range record.VideoFiles {
-			if videoFile.Type == "ts" {
-				if config.IsDryRun {
-					logger.Info(fmt.Sprintf("(Dry Run) Delete videoFile id: %d, filename: %s", videoFile.Id, videoFile.FileName))
-				} else {
-					logger.Info(fmt.Sprintf("Delete videoFile id: %d, filename: %s", videoFile.Id, videoFile.FileName))
-					err := epgStationClient.DeleteVideoFile(videoFile.Id)
-					if err != nil {
-						logger.Error(err.Error())
-						continue
-					}
-				}
-			}
+	metrics := NewMetrics()
+	sinks := NewReportSinks(config)
+
+	switch config.RunMode {
+	case "daemon":
+		RunDaemon(config, backend, policy, metrics, sinks)
+	case "oneshot":
+		if err := runSweep(config, backend, policy, metrics, sinks); err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
 		}
+	case "apply":
+		if config.ApplyPlanFile == "" {
+			logger.Error("APPLY_PLAN_FILE must be set when RUN_MODE=apply")
+			os.Exit(1)
+		}
+		if err := RunApply(config, backend, metrics, sinks); err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+	default:
+		logger.Error(fmt.Sprintf("Unknown run mode: %s", config.RunMode))
+		os.Exit(1)
 	}
 }
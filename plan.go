@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// PlanEntry is a single video file a Plan would delete.
+type PlanEntry struct {
+	Id     int64  `json:"id"`
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	Reason string `json:"reason"`
+}
+
+// SeriesPlanGroup collects the PlanEntries that share a series key, along
+// with their totals.
+type SeriesPlanGroup struct {
+	Series  string      `json:"series"`
+	Entries []PlanEntry `json:"entries"`
+	Count   int         `json:"count"`
+	Bytes   int64       `json:"bytes"`
+}
+
+// Plan is the machine-readable description of what a sweep would delete. It
+// can be written to a file via PLAN_FILE and later replayed with
+// RUN_MODE=apply, so operators can review before applying in CI/GitOps
+// workflows instead of trusting a live scan.
+type Plan struct {
+	GeneratedAt time.Time         `json:"generatedAt"`
+	Groups      []SeriesPlanGroup `json:"groups"`
+	TotalCount  int               `json:"totalCount"`
+	TotalBytes  int64             `json:"totalBytes"`
+}
+
+// seriesKey groups a record the same way retain-by-count-per-series does:
+// by its EPGStation recording rule, falling back to its own name for
+// manually recorded items that have no rule.
+func seriesKey(record RecordedItem) string {
+	if record.RuleId != nil {
+		return fmt.Sprintf("rule-%d", *record.RuleId)
+	}
+	return "unclassified: " + record.Name
+}
+
+// BuildPlan groups deletion candidates' ts files by series and totals each
+// group.
+func BuildPlan(candidates []DeletionCandidate) *Plan {
+	groupsByKey := make(map[string]*SeriesPlanGroup)
+	var order []string
+
+	for _, candidate := range candidates {
+		record := candidate.Record
+		for _, videoFile := range record.VideoFiles {
+			if videoFile.Type != "ts" {
+				continue
+			}
+
+			key := seriesKey(record)
+			group, ok := groupsByKey[key]
+			if !ok {
+				group = &SeriesPlanGroup{Series: key}
+				groupsByKey[key] = group
+				order = append(order, key)
+			}
+
+			group.Entries = append(group.Entries, PlanEntry{Id: videoFile.Id, Name: record.Name, Size: videoFile.Size, Reason: candidate.Reason})
+			group.Count++
+			group.Bytes += videoFile.Size
+		}
+	}
+
+	sort.Strings(order)
+
+	plan := &Plan{GeneratedAt: time.Now()}
+	for _, key := range order {
+		group := groupsByKey[key]
+		plan.Groups = append(plan.Groups, *group)
+		plan.TotalCount += group.Count
+		plan.TotalBytes += group.Bytes
+	}
+
+	return plan
+}
+
+func (p *Plan) Summary() string {
+	return fmt.Sprintf("%d series, %d file(s), %d bytes", len(p.Groups), p.TotalCount, p.TotalBytes)
+}
+
+// WriteTable renders a human-readable plan, grouped by series, for terminal
+// review.
+func (p *Plan) WriteTable(w io.Writer) {
+	for _, group := range p.Groups {
+		fmt.Fprintf(w, "%s (%d file(s), %d bytes)\n", group.Series, group.Count, group.Bytes)
+		for _, entry := range group.Entries {
+			fmt.Fprintf(w, "  - id=%d name=%q size=%d reason=%s\n", entry.Id, entry.Name, entry.Size, entry.Reason)
+		}
+	}
+	fmt.Fprintf(w, "Total: %s\n", p.Summary())
+}
+
+func (p *Plan) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(p)
+}
+
+// WritePlanFile writes the plan as JSON to path.
+func WritePlanFile(p *Plan, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return p.WriteJSON(f)
+}
+
+// ReadPlanFile reads a plan previously written by WritePlanFile.
+func ReadPlanFile(path string) (*Plan, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var p Plan
+	if err := json.NewDecoder(f).Decode(&p); err != nil {
+		return nil, fmt.Errorf("failed to parse plan file %s: %w", path, err)
+	}
+
+	return &p, nil
+}
+
+// confirmPlan prints the plan and blocks on stdin for a y/N answer.
+func confirmPlan(plan *Plan, in io.Reader, out io.Writer) bool {
+	plan.WriteTable(out)
+	fmt.Fprint(out, "Proceed with deletion? [y/N]: ")
+
+	scanner := bufio.NewScanner(in)
+	if !scanner.Scan() {
+		return false
+	}
+
+	answer := strings.ToLower(strings.TrimSpace(scanner.Text()))
+	return answer == "y" || answer == "yes"
+}
+
+// RunApply replays a previously generated plan file against backend,
+// without re-scanning or re-evaluating the deletion policy.
+func RunApply(config Config, backend RecordingBackend, metrics *Metrics, sinks []ReportSink) error {
+	plan, err := ReadPlanFile(config.ApplyPlanFile)
+	if err != nil {
+		return err
+	}
+
+	logger.Info(fmt.Sprintf("Applying plan from %s: %s", config.ApplyPlanFile, plan.Summary()))
+
+	report := NewSweepReport()
+
+	for _, group := range plan.Groups {
+		for _, entry := range group.Entries {
+			if config.IsDryRun {
+				logger.Info(fmt.Sprintf("(Dry Run) Delete videoFile id: %d, name: %s", entry.Id, entry.Name))
+				report.AddDeletion(RecordedItem{Name: entry.Name}, VideoFile{Id: entry.Id, Size: entry.Size}, entry.Reason)
+				continue
+			}
+
+			logger.Info(fmt.Sprintf("Delete videoFile id: %d, name: %s", entry.Id, entry.Name))
+			if err := backend.DeleteVideo(entry.Id); err != nil {
+				logger.Error(err.Error())
+				metrics.RecordError()
+				report.AddError(err)
+				continue
+			}
+			metrics.RecordDeletion(entry.Size)
+			report.AddDeletion(RecordedItem{Name: entry.Name}, VideoFile{Id: entry.Id, Size: entry.Size}, entry.Reason)
+		}
+	}
+
+	metrics.RecordSweepCompleted()
+	NotifySinks(sinks, report, config.NotifyOnlyOnError)
+
+	return nil
+}
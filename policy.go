@@ -0,0 +1,348 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Decision is the verdict a Rule reaches for a given record.
+type Decision int
+
+const (
+	// Abstain means the rule has no opinion; evaluation falls through to
+	// the next rule in the chain.
+	Abstain Decision = iota
+	Retain
+	Delete
+)
+
+// Rule is a single unit of the deletion-policy DSL. Rules are evaluated in
+// the order they are configured, and the first one to return a non-Abstain
+// Decision wins.
+type Rule interface {
+	Name() string
+	Evaluate(record RecordedItem, allRecords []RecordedItem) Decision
+}
+
+// DeletionPolicy composes a PolicyEngine. It exists as a thin wrapper so call
+// sites (e.g. extractTargetRecordItems) don't need to know about the engine
+// directly.
+type DeletionPolicy struct {
+	Engine *PolicyEngine
+}
+
+// NewDeletionPolicy builds the default policy: retain everything younger
+// than retainDuration, delete everything older. This is the behavior used
+// when POLICY_CONFIG_PATH is not set.
+func NewDeletionPolicy(retainDuration time.Duration) DeletionPolicy {
+	return DeletionPolicy{Engine: NewPolicyEngine([]Rule{NewAgeRule(retainDuration)})}
+}
+
+// NewDeletionPolicyFromConfig loads a rule chain from a YAML or JSON file
+// (selected by file extension) and builds a DeletionPolicy from it.
+func NewDeletionPolicyFromConfig(path string) (DeletionPolicy, error) {
+	engine, err := LoadPolicyEngine(path)
+	if err != nil {
+		return DeletionPolicy{}, err
+	}
+	return DeletionPolicy{Engine: engine}, nil
+}
+
+// PolicyEngine evaluates a chain of Rules against each RecordedItem and
+// returns the items that should be deleted.
+type PolicyEngine struct {
+	Rules []Rule
+}
+
+func NewPolicyEngine(rules []Rule) *PolicyEngine {
+	return &PolicyEngine{Rules: rules}
+}
+
+// DeletionCandidate pairs a record marked for deletion with the name of the
+// rule that reached that verdict, so callers can report why a deletion
+// happened.
+type DeletionCandidate struct {
+	Record RecordedItem
+	Reason string
+}
+
+// Evaluate applies the base eligibility gate (not protected, has both a ts
+// and an encoded file) and then runs the configured rule chain for each
+// surviving record, logging which rule decided its fate.
+func (e *PolicyEngine) Evaluate(records []RecordedItem) []DeletionCandidate {
+	dst := make([]DeletionCandidate, 0)
+
+	for _, record := range records {
+		hasTS := false
+		hasEncoded := false
+		for _, vf := range record.VideoFiles {
+			if vf.Type == "ts" {
+				hasTS = true
+			} else if vf.Type == "encoded" {
+				hasEncoded = true
+			}
+		}
+
+		if record.IsProtected || !hasTS || !hasEncoded {
+			continue
+		}
+
+		decision := Retain
+		ruleName := "default"
+		for _, rule := range e.Rules {
+			if d := rule.Evaluate(record, records); d != Abstain {
+				decision = d
+				ruleName = rule.Name()
+				break
+			}
+		}
+
+		if decision == Delete {
+			logger.Info(fmt.Sprintf("Record id: %d, name: %s marked for deletion by rule %q", record.Id, record.Name, ruleName))
+			dst = append(dst, DeletionCandidate{Record: record, Reason: ruleName})
+		} else {
+			logger.Debug(fmt.Sprintf("Record id: %d, name: %s retained by rule %q", record.Id, record.Name, ruleName))
+		}
+	}
+
+	return dst
+}
+
+// RuleConfig is the on-disk shape of a single rule, as loaded from a YAML or
+// JSON policy config file. Only the fields relevant to Type are read.
+type RuleConfig struct {
+	Type           string  `json:"type" yaml:"type"`
+	RetainDuration string  `json:"retainDuration" yaml:"retainDuration"`
+	Count          int     `json:"count" yaml:"count"`
+	QuotaBytes     int64   `json:"quotaBytes" yaml:"quotaBytes"`
+	GenreIds       []int64 `json:"genreIds" yaml:"genreIds"`
+	NameRegex      string  `json:"nameRegex" yaml:"nameRegex"`
+}
+
+// PolicyConfig is the top-level shape of a policy config file: an ordered
+// list of rules, evaluated first-match-wins per record.
+type PolicyConfig struct {
+	Rules []RuleConfig `json:"rules" yaml:"rules"`
+}
+
+// LoadPolicyEngine reads a policy config file and builds the corresponding
+// PolicyEngine. YAML is used for .yaml/.yml paths, JSON otherwise.
+func LoadPolicyEngine(path string) (*PolicyEngine, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg PolicyConfig
+	if strings.HasSuffix(path, ".yaml") || strings.HasSuffix(path, ".yml") {
+		err = yaml.Unmarshal(raw, &cfg)
+	} else {
+		err = json.Unmarshal(raw, &cfg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse policy config %s: %w", path, err)
+	}
+
+	rules := make([]Rule, 0, len(cfg.Rules))
+	for _, ruleCfg := range cfg.Rules {
+		rule, err := buildRule(ruleCfg)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+
+	return NewPolicyEngine(rules), nil
+}
+
+func buildRule(cfg RuleConfig) (Rule, error) {
+	switch cfg.Type {
+	case "retain-by-age":
+		d, err := time.ParseDuration(cfg.RetainDuration)
+		if err != nil {
+			return nil, fmt.Errorf("retain-by-age: invalid retainDuration %q: %w", cfg.RetainDuration, err)
+		}
+		return NewAgeRule(d), nil
+	case "retain-by-count-per-series":
+		return NewCountPerSeriesRule(cfg.Count), nil
+	case "retain-by-total-size-quota":
+		return NewTotalSizeQuotaRule(cfg.QuotaBytes), nil
+	case "retain-if-genre-matches":
+		return NewGenreMatchRule(cfg.GenreIds), nil
+	case "never-delete-if-name-regex":
+		re, err := regexp.Compile(cfg.NameRegex)
+		if err != nil {
+			return nil, fmt.Errorf("never-delete-if-name-regex: invalid nameRegex %q: %w", cfg.NameRegex, err)
+		}
+		return NewNameRegexRule(re), nil
+	default:
+		return nil, fmt.Errorf("unknown policy rule type %q", cfg.Type)
+	}
+}
+
+// AgeRule implements retain-by-age: records younger than RetainDuration are
+// retained, older ones are deleted. It never abstains, so it is suitable as
+// a catch-all fallback at the end of a rule chain.
+type AgeRule struct {
+	RetainDuration time.Duration
+}
+
+func NewAgeRule(retainDuration time.Duration) *AgeRule {
+	return &AgeRule{RetainDuration: retainDuration}
+}
+
+func (r *AgeRule) Name() string { return "retain-by-age" }
+
+func (r *AgeRule) Evaluate(record RecordedItem, _ []RecordedItem) Decision {
+	elapsed := time.Since(time.UnixMilli(record.StartAt))
+	if elapsed > r.RetainDuration {
+		return Delete
+	}
+	return Retain
+}
+
+// NameRegexRule implements never-delete-if-name-regex: records whose name
+// matches Regex are always retained. It abstains otherwise, deferring to
+// later rules.
+type NameRegexRule struct {
+	Regex *regexp.Regexp
+}
+
+func NewNameRegexRule(re *regexp.Regexp) *NameRegexRule {
+	return &NameRegexRule{Regex: re}
+}
+
+func (r *NameRegexRule) Name() string { return "never-delete-if-name-regex" }
+
+func (r *NameRegexRule) Evaluate(record RecordedItem, _ []RecordedItem) Decision {
+	if r.Regex.MatchString(record.Name) {
+		return Retain
+	}
+	return Abstain
+}
+
+// GenreMatchRule implements retain-if-genre-matches: records carrying one of
+// GenreIds are retained. It abstains otherwise.
+type GenreMatchRule struct {
+	GenreIds map[int64]struct{}
+}
+
+func NewGenreMatchRule(genreIds []int64) *GenreMatchRule {
+	set := make(map[int64]struct{}, len(genreIds))
+	for _, id := range genreIds {
+		set[id] = struct{}{}
+	}
+	return &GenreMatchRule{GenreIds: set}
+}
+
+func (r *GenreMatchRule) Name() string { return "retain-if-genre-matches" }
+
+func (r *GenreMatchRule) Evaluate(record RecordedItem, _ []RecordedItem) Decision {
+	for _, id := range record.GenreIds {
+		if _, ok := r.GenreIds[id]; ok {
+			return Retain
+		}
+	}
+	return Abstain
+}
+
+// CountPerSeriesRule implements retain-by-count-per-series: the Count most
+// recent recordings sharing a RuleId are retained, the rest of that group is
+// deleted. Records without a RuleId (not produced by a recording rule)
+// abstain, deferring to later rules.
+type CountPerSeriesRule struct {
+	Count int
+}
+
+func NewCountPerSeriesRule(count int) *CountPerSeriesRule {
+	return &CountPerSeriesRule{Count: count}
+}
+
+func (r *CountPerSeriesRule) Name() string { return "retain-by-count-per-series" }
+
+func (r *CountPerSeriesRule) Evaluate(record RecordedItem, allRecords []RecordedItem) Decision {
+	if record.RuleId == nil {
+		return Abstain
+	}
+
+	var series []RecordedItem
+	for _, other := range allRecords {
+		if other.RuleId != nil && *other.RuleId == *record.RuleId {
+			series = append(series, other)
+		}
+	}
+
+	sort.Slice(series, func(i, j int) bool { return series[i].StartAt > series[j].StartAt })
+
+	for i, other := range series {
+		if other.Id != record.Id {
+			continue
+		}
+		if i < r.Count {
+			return Retain
+		}
+		return Delete
+	}
+
+	return Abstain
+}
+
+// TotalSizeQuotaRule implements retain-by-total-size-quota: ts files are
+// kept newest-first until their cumulative size would exceed QuotaBytes; the
+// remaining, older ts files are deleted. This lets disk pressure drive
+// cleanup instead of a fixed retention window.
+type TotalSizeQuotaRule struct {
+	QuotaBytes int64
+}
+
+func NewTotalSizeQuotaRule(quotaBytes int64) *TotalSizeQuotaRule {
+	return &TotalSizeQuotaRule{QuotaBytes: quotaBytes}
+}
+
+func (r *TotalSizeQuotaRule) Name() string { return "retain-by-total-size-quota" }
+
+func (r *TotalSizeQuotaRule) Evaluate(record RecordedItem, allRecords []RecordedItem) Decision {
+	tsSize := func(item RecordedItem) (int64, bool) {
+		for _, vf := range item.VideoFiles {
+			if vf.Type == "ts" {
+				return vf.Size, true
+			}
+		}
+		return 0, false
+	}
+
+	if _, ok := tsSize(record); !ok {
+		return Abstain
+	}
+
+	sorted := make([]RecordedItem, len(allRecords))
+	copy(sorted, allRecords)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].StartAt > sorted[j].StartAt })
+
+	var cumulative int64
+	for _, item := range sorted {
+		size, ok := tsSize(item)
+		if !ok {
+			continue
+		}
+
+		withinQuota := cumulative+size <= r.QuotaBytes
+		cumulative += size
+
+		if item.Id == record.Id {
+			if withinQuota {
+				return Retain
+			}
+			return Delete
+		}
+	}
+
+	return Abstain
+}
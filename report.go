@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DeletedEntry describes a single video file removed during a sweep.
+type DeletedEntry struct {
+	Id     int64  `json:"id"`
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	Reason string `json:"reason"`
+}
+
+// SweepReport is the structured summary of one sweep, suitable for rendering
+// to a notification sink as-is.
+type SweepReport struct {
+	Timestamp   time.Time      `json:"timestamp"`
+	Deletions   []DeletedEntry `json:"deletions"`
+	TotalCount  int            `json:"totalCount"`
+	TotalBytes  int64          `json:"totalBytes"`
+	ErrorCount  int            `json:"errorCount"`
+	ErrorDetail []string       `json:"errorDetail,omitempty"`
+}
+
+func NewSweepReport() *SweepReport {
+	return &SweepReport{Timestamp: time.Now()}
+}
+
+func (r *SweepReport) AddDeletion(record RecordedItem, videoFile VideoFile, reason string) {
+	r.Deletions = append(r.Deletions, DeletedEntry{Id: videoFile.Id, Name: record.Name, Size: videoFile.Size, Reason: reason})
+	r.TotalCount++
+	r.TotalBytes += videoFile.Size
+}
+
+func (r *SweepReport) AddError(err error) {
+	r.ErrorCount++
+	r.ErrorDetail = append(r.ErrorDetail, err.Error())
+}
+
+func (r *SweepReport) Summary() string {
+	return fmt.Sprintf("Deleted %d file(s), reclaimed %d bytes, %d error(s)", r.TotalCount, r.TotalBytes, r.ErrorCount)
+}
+
+// ReportSink receives a SweepReport after each sweep.
+type ReportSink interface {
+	Send(report *SweepReport) error
+}
+
+// NewReportSinks builds the sinks selected by the DISCORD_WEBHOOK_URL,
+// SLACK_WEBHOOK_URL, REPORT_WEBHOOK_URL and REPORT_FILE_DIR config fields. Any subset (or none) may be configured at once; a sweep notifies
+// all of them.
+func NewReportSinks(config Config) []ReportSink {
+	sinks := make([]ReportSink, 0)
+
+	if config.DiscordWebhookURL != "" {
+		sinks = append(sinks, NewDiscordWebhookSink(config.DiscordWebhookURL))
+	}
+	if config.SlackWebhookURL != "" {
+		sinks = append(sinks, NewSlackWebhookSink(config.SlackWebhookURL))
+	}
+	if config.ReportWebhookURL != "" {
+		sinks = append(sinks, NewHTTPWebhookSink(config.ReportWebhookURL))
+	}
+	if config.ReportFileDir != "" {
+		sinks = append(sinks, NewFileReportSink(config.ReportFileDir))
+	}
+
+	return sinks
+}
+
+// NotifySinks sends report to every sink, skipping the send entirely when
+// onlyOnError is set and the sweep had no errors.
+func NotifySinks(sinks []ReportSink, report *SweepReport, onlyOnError bool) {
+	if onlyOnError && report.ErrorCount == 0 {
+		return
+	}
+
+	for _, sink := range sinks {
+		if err := sink.Send(report); err != nil {
+			logger.Error(err.Error())
+		}
+	}
+}
+
+// HTTPWebhookSink POSTs the report as JSON to an arbitrary HTTP endpoint.
+type HTTPWebhookSink struct {
+	url string
+}
+
+func NewHTTPWebhookSink(url string) *HTTPWebhookSink {
+	return &HTTPWebhookSink{url: url}
+}
+
+func (s *HTTPWebhookSink) Send(report *SweepReport) error {
+	return postJSON(s.url, report)
+}
+
+// DiscordWebhookSink posts the report summary as a Discord webhook message.
+type DiscordWebhookSink struct {
+	url string
+}
+
+func NewDiscordWebhookSink(url string) *DiscordWebhookSink {
+	return &DiscordWebhookSink{url: url}
+}
+
+func (s *DiscordWebhookSink) Send(report *SweepReport) error {
+	return postJSON(s.url, map[string]string{"content": report.Summary()})
+}
+
+// SlackWebhookSink posts the report summary as a Slack incoming webhook
+// message.
+type SlackWebhookSink struct {
+	url string
+}
+
+func NewSlackWebhookSink(url string) *SlackWebhookSink {
+	return &SlackWebhookSink{url: url}
+}
+
+func (s *SlackWebhookSink) Send(report *SweepReport) error {
+	return postJSON(s.url, map[string]string{"text": report.Summary()})
+}
+
+func postJSON(url string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	hc := BuildHttpClient()
+	resp, err := hc.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s returned status %d", url, resp.StatusCode)
+	}
+
+	return nil
+}
+
+// FileReportSink writes each report as its own JSON file under dir, named by
+// the sweep's date so a day's reports can be found without parsing content.
+type FileReportSink struct {
+	dir string
+}
+
+func NewFileReportSink(dir string) *FileReportSink {
+	return &FileReportSink{dir: dir}
+}
+
+func (s *FileReportSink) Send(report *SweepReport) error {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return err
+	}
+
+	body, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	fileName := fmt.Sprintf("report-%s.json", report.Timestamp.Format("2006-01-02T15-04-05"))
+	return os.WriteFile(filepath.Join(s.dir, fileName), body, 0644)
+}